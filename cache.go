@@ -0,0 +1,95 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheStats reports cumulative activity of a SearchClient's result cache.
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+type cacheItem struct {
+	key       string
+	resp      *SearchResponse
+	expiresAt time.Time
+}
+
+// resultCache is an LRU cache of SearchResponse values keyed by canonical
+// query string, with a per-entry TTL. A capacity of 0 means unbounded.
+type resultCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+
+	hits, misses, evictions int64
+}
+
+func newResultCache(ttl time.Duration, capacity int) *resultCache {
+	return &resultCache{
+		ttl:      ttl,
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *resultCache) get(key string) (*SearchResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	item := elem.Value.(*cacheItem)
+	if time.Now().After(item.expiresAt) {
+		c.removeElement(elem)
+		c.misses++
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	c.hits++
+	resp := *item.resp
+	return &resp, true
+}
+
+func (c *resultCache) set(key string, resp *SearchResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored := *resp
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheItem).resp = &stored
+		elem.Value.(*cacheItem).expiresAt = time.Now().Add(c.ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	elem := c.order.PushFront(&cacheItem{key: key, resp: &stored, expiresAt: time.Now().Add(c.ttl)})
+	c.entries[key] = elem
+	if c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.removeElement(oldest)
+		c.evictions++
+	}
+}
+
+func (c *resultCache) removeElement(elem *list.Element) {
+	item := elem.Value.(*cacheItem)
+	delete(c.entries, item.key)
+	c.order.Remove(elem)
+}
+
+func (c *resultCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Evictions: c.evictions}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	OrderByAsc  = -1
+	OrderByAsIs = 0
+	OrderByDesc = 1
+)
+
+// orderableFields is the whitelist of fields the server is known to sort by.
+// Validating client-side avoids a round-trip for a request doomed to fail.
+var orderableFields = map[string]bool{
+	"Id":   true,
+	"Age":  true,
+	"Name": true,
+}
+
+type User struct {
+	Id     int
+	Name   string
+	Age    int
+	About  string
+	Gender string
+}
+
+// OrderKey is one entry of a multi-key sort: Field is validated against
+// orderableFields, By is one of OrderByAsc/OrderByAsIs/OrderByDesc.
+type OrderKey struct {
+	Field string
+	By    int
+}
+
+type SearchRequest struct {
+	Limit      int
+	Offset     int
+	Query      string
+	OrderField string
+	OrderBy    int
+
+	// Cursor, when set, is an opaque token returned by a previous
+	// SearchResponse.NextCursor and replaces Offset for stable paging
+	// across mutations. Setting both Cursor and Offset is an error.
+	Cursor string
+
+	// OrderFields, when non-empty, overrides OrderField/OrderBy and
+	// requests a multi-key sort.
+	OrderFields []OrderKey
+}
+
+type SearchErrorResponse struct {
+	Error string
+}
+
+type SearchResponse struct {
+	Users    []User
+	NextPage bool
+
+	// NextCursor is set alongside NextPage and can be fed back into the
+	// next SearchRequest.Cursor to continue paging.
+	NextCursor string
+}
+
+// ClientOptions configures the transport behaviour of a SearchClient: the
+// per-request timeout, the retry policy for transient failures, and the
+// circuit breaker that stops hammering a server that keeps failing.
+// The zero value disables retries and the breaker, keeping the historical
+// single-attempt, one-second-timeout behaviour.
+type ClientOptions struct {
+	Timeout time.Duration
+
+	// MaxRetries is the number of additional attempts made after a
+	// retryable failure (a timeout or a 5xx response). 0 means no retries.
+	MaxRetries int
+
+	// RetryBackoff returns how long to wait before the given attempt
+	// (1-indexed). Defaults to exponential backoff with jitter.
+	RetryBackoff func(attempt int) time.Duration
+
+	// BreakerThreshold is the number of consecutive retryable failures
+	// that trips the breaker. 0 disables the breaker.
+	BreakerThreshold int
+
+	// BreakerCooldown is how long the breaker stays open once tripped.
+	// Defaults to one second.
+	BreakerCooldown time.Duration
+}
+
+type SearchClient struct {
+	AccessToken string
+	URL         string
+	Options     ClientOptions
+
+	// httpClient, when set, is used instead of a client built from
+	// Options.Timeout - mainly useful for tests that need a custom
+	// transport.
+	httpClient *http.Client
+
+	mu               sync.Mutex
+	consecutiveFails int
+	breakerOpenUntil time.Time
+
+	// cache, when set via WithCache, holds recent results and coalesces
+	// concurrent identical requests.
+	cache   *resultCache
+	sfGroup singleflight.Group
+}
+
+// WithCache turns on an in-memory result cache keyed by the canonical query
+// plus AccessToken, holding up to size entries for ttl each with LRU
+// eviction. It returns srv so it can be chained off a constructor. Calls
+// made while a cache entry is live are coalesced via singleflight so
+// concurrent identical requests only hit the network once.
+func (srv *SearchClient) WithCache(ttl time.Duration, size int) *SearchClient {
+	srv.cache = newResultCache(ttl, size)
+	return srv
+}
+
+// CacheStats reports cumulative cache activity. It is the zero value when
+// no cache has been configured via WithCache.
+func (srv *SearchClient) CacheStats() CacheStats {
+	if srv.cache == nil {
+		return CacheStats{}
+	}
+	return srv.cache.stats()
+}
+
+func cacheKey(params url.Values, accessToken string) string {
+	return params.Encode() + "|" + accessToken
+}
+
+func (srv *SearchClient) client() *http.Client {
+	if srv.httpClient != nil {
+		return srv.httpClient
+	}
+	timeout := srv.Options.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+func defaultRetryBackoff(attempt int) time.Duration {
+	base := time.Duration(1<<uint(attempt-1)) * 100 * time.Millisecond
+	jitter := time.Duration(rand.Int63n(int64(50 * time.Millisecond)))
+	return base + jitter
+}
+
+func (srv *SearchClient) breakerOpen() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return !srv.breakerOpenUntil.IsZero() && time.Now().Before(srv.breakerOpenUntil)
+}
+
+func (srv *SearchClient) recordSuccess() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.consecutiveFails = 0
+}
+
+func (srv *SearchClient) recordFailure() {
+	threshold := srv.Options.BreakerThreshold
+	if threshold <= 0 {
+		return
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.consecutiveFails++
+	if srv.consecutiveFails >= threshold {
+		cooldown := srv.Options.BreakerCooldown
+		if cooldown == 0 {
+			cooldown = time.Second
+		}
+		srv.breakerOpenUntil = time.Now().Add(cooldown)
+		srv.consecutiveFails = 0
+	}
+}
+
+// FindUsers is a thin wrapper around FindUsersContext using
+// context.Background(), kept for backwards compatibility.
+func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
+	return srv.FindUsersContext(context.Background(), req)
+}
+
+// FindUsersContext behaves like FindUsers but honors ctx's deadline and
+// cancellation: once ctx is done, any in-flight request is aborted and
+// ctx.Err() is returned directly instead of a generic timeout error.
+func (srv *SearchClient) FindUsersContext(ctx context.Context, req SearchRequest) (*SearchResponse, error) {
+	if req.Limit < 0 {
+		return nil, validationError("Limit", fmt.Errorf("limit must be > 0"))
+	}
+	if req.Limit > 25 {
+		req.Limit = 25
+	}
+	if req.Offset < 0 {
+		return nil, validationError("Offset", fmt.Errorf("offset must be > 0"))
+	}
+	if req.Cursor != "" && req.Offset != 0 {
+		return nil, validationError("Cursor", fmt.Errorf("cannot use both Offset and Cursor"))
+	}
+	if req.OrderField != "" && !orderableFields[req.OrderField] {
+		return nil, &SearchError{Kind: KindBadOrderField, Field: req.OrderField, Err: fmt.Errorf("invalid order field: %s: %w", req.OrderField, ErrBadOrderField)}
+	}
+	for _, key := range req.OrderFields {
+		if !orderableFields[key.Field] {
+			return nil, &SearchError{Kind: KindBadOrderField, Field: key.Field, Err: fmt.Errorf("invalid order field: %s: %w", key.Field, ErrBadOrderField)}
+		}
+	}
+
+	offset := req.Offset
+	if req.Cursor != "" {
+		decoded, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, validationError("Cursor", err)
+		}
+		offset = decoded
+	}
+
+	searcherParams := url.Values{}
+	searcherParams.Set("limit", strconv.Itoa(req.Limit+1))
+	searcherParams.Set("offset", strconv.Itoa(offset))
+	searcherParams.Set("query", req.Query)
+	if len(req.OrderFields) > 0 {
+		data, err := json.Marshal(req.OrderFields)
+		if err != nil {
+			return nil, err
+		}
+		searcherParams.Set("order_fields", string(data))
+	} else {
+		searcherParams.Set("order_field", req.OrderField)
+		searcherParams.Set("order_by", strconv.Itoa(req.OrderBy))
+	}
+
+	if srv.cache == nil {
+		return srv.search(ctx, searcherParams, req, offset)
+	}
+
+	key := cacheKey(searcherParams, srv.AccessToken)
+	if resp, ok := srv.cache.get(key); ok {
+		return resp, nil
+	}
+
+	v, err, _ := srv.sfGroup.Do(key, func() (interface{}, error) {
+		return srv.search(ctx, searcherParams, req, offset)
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := v.(*SearchResponse)
+	srv.cache.set(key, resp)
+	return resp, nil
+}
+
+// search runs the validated request through the retry/breaker loop,
+// independent of caching.
+func (srv *SearchClient) search(ctx context.Context, searcherParams url.Values, req SearchRequest, offset int) (*SearchResponse, error) {
+	backoff := srv.Options.RetryBackoff
+	if backoff == nil {
+		backoff = defaultRetryBackoff
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= srv.Options.MaxRetries; attempt++ {
+		if srv.breakerOpen() {
+			return nil, &SearchError{Kind: KindTransport, Err: ErrCircuitOpen}
+		}
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff(attempt)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		result, retryable, err := srv.attempt(ctx, searcherParams, req, offset)
+		if err == nil {
+			srv.recordSuccess()
+			return result, nil
+		}
+		lastErr = err
+		if !retryable {
+			return nil, err
+		}
+		srv.recordFailure()
+	}
+	return nil, lastErr
+}
+
+// attempt performs a single HTTP round-trip and classifies the outcome:
+// the returned bool reports whether the error, if any, is worth retrying.
+func (srv *SearchClient) attempt(ctx context.Context, params url.Values, req SearchRequest, offset int) (*SearchResponse, bool, error) {
+	searcherReq, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	searcherReq.Header.Add("AccessToken", srv.AccessToken)
+
+	resp, err := srv.client().Do(searcherReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		if urlErr, ok := err.(*url.Error); ok {
+			if urlErr.Timeout() {
+				return nil, true, &SearchError{Kind: KindTimeout, Err: fmt.Errorf("timeout for %s", params.Encode())}
+			}
+		}
+		return nil, false, &SearchError{Kind: KindTransport, Err: fmt.Errorf("unknown error %s", err)}
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, &SearchError{Kind: KindTransport, Err: err}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, false, &SearchError{Kind: KindAuth, Status: resp.StatusCode, Err: ErrBadAccessToken}
+	case resp.StatusCode == http.StatusInternalServerError:
+		return nil, true, &SearchError{Kind: KindServer, Status: resp.StatusCode, Err: ErrSearchServerFatal}
+	case resp.StatusCode >= 500:
+		return nil, true, &SearchError{Kind: KindServer, Status: resp.StatusCode, Err: fmt.Errorf("search server error: status %d", resp.StatusCode)}
+	case resp.StatusCode == http.StatusBadRequest:
+		errResp := SearchErrorResponse{}
+		err = json.Unmarshal(body, &errResp)
+		if err != nil {
+			return nil, false, &SearchError{Kind: KindUnmarshal, Status: resp.StatusCode, Err: fmt.Errorf("cant unpack error json: %w", err)}
+		}
+		if errResp.Error == "ErrorBadOrderField" {
+			return nil, false, &SearchError{Kind: KindBadOrderField, Status: resp.StatusCode, Field: req.OrderField, Err: fmt.Errorf("OrderField %s invalid: %w", req.OrderField, ErrBadOrderField)}
+		}
+		return nil, false, &SearchError{Kind: KindServer, Status: resp.StatusCode, Err: fmt.Errorf("%w: %s", ErrUnknownBadRequest, errResp.Error)}
+	}
+
+	data := []User{}
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		return nil, false, &SearchError{Kind: KindUnmarshal, Err: fmt.Errorf("cant unpack result json: %w", err)}
+	}
+
+	result := SearchResponse{}
+	if len(data) == req.Limit+1 {
+		result.NextPage = true
+		data = data[:len(data)-1]
+		result.NextCursor = encodeCursor(offset + req.Limit)
+	}
+	result.Users = data
+	return &result, false, nil
+}
+
+func encodeCursor(offset int) string {
+	return base64.URLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor: %s", err)
+	}
+	offset, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor: %s", err)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("invalid cursor: negative offset")
+	}
+	return offset, nil
+}
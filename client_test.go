@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
-	"strings"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -46,11 +49,23 @@ func BabbleServer(w http.ResponseWriter, r *http.Request) {
 		io.WriteString(w, ResponseEmpty)
 	case "LargeResponse":
 		w.WriteHeader(http.StatusOK)
+		offset, _ := strconv.Atoi(r.FormValue("offset"))
+		limit, _ := strconv.Atoi(r.FormValue("limit"))
+		const total = 30
 		var users []User
-		for i := 0; i <= 25; i++ {
-			users = append(users, User{
-				Id: i,
-			})
+		for i := offset; i < offset+limit && i < total; i++ {
+			users = append(users, User{Id: i})
+		}
+		data, _ := json.Marshal(users)
+		io.WriteString(w, string(data))
+	case "CursorPaging":
+		w.WriteHeader(http.StatusOK)
+		offset, _ := strconv.Atoi(r.FormValue("offset"))
+		limit, _ := strconv.Atoi(r.FormValue("limit"))
+		const total = 10
+		var users []User
+		for i := offset; i < offset+limit && i < total; i++ {
+			users = append(users, User{Id: i})
 		}
 		data, _ := json.Marshal(users)
 		io.WriteString(w, string(data))
@@ -60,6 +75,34 @@ func BabbleServer(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// newFlakyServer returns a test server whose handler fails with failStatus
+// for the first failCount requests and then serves successBody with a 200.
+// The returned counter tracks the total number of requests it has seen.
+func newFlakyServer(failCount int, failStatus int, successBody string) (*httptest.Server, *int32) {
+	var hits int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		if int(n) <= failCount {
+			w.WriteHeader(failStatus)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, successBody)
+	}
+	return httptest.NewServer(http.HandlerFunc(handler)), &hits
+}
+
+// newCountingServer wraps handler and returns a counter of how many requests
+// it has served.
+func newCountingServer(handler http.HandlerFunc) (*httptest.Server, *int32) {
+	var hits int32
+	wrapped := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		handler(w, r)
+	}
+	return httptest.NewServer(http.HandlerFunc(wrapped)), &hits
+}
+
 func NewSearchClient(act, url string) SearchClient {
 	return SearchClient{
 		AccessToken: act,
@@ -103,43 +146,65 @@ func TestSearchClient_FindUsers(t *testing.T) {
 		req := NewSearchRequest(-1, 1, 0, "", "")
 		resp, err := sc.FindUsers(req)
 		respNil(t, resp, "Resp must be nil")
-		customEqual(t, err.Error(), "limit must be > 0")
+		searchErr := asSearchError(t, err)
+		customEqual(t, searchErr.Kind, KindValidation)
+		customEqual(t, searchErr.Field, "Limit")
 	})
 	t.Run("negative_offset", func(t *testing.T) {
 		req := NewSearchRequest(1, -1, 0, "", "")
 		resp, err := sc.FindUsers(req)
 		respNil(t, resp, "Resp must be nil")
-		customEqual(t, err.Error(), "offset must be > 0")
+		searchErr := asSearchError(t, err)
+		customEqual(t, searchErr.Kind, KindValidation)
+		customEqual(t, searchErr.Field, "Offset")
 	})
 	t.Run("search_server_fatal_error", func(t *testing.T) {
 		req := NewSearchRequest(1, 1, 0, "Fatal", "")
 		resp, err := sc.FindUsers(req)
 		respNil(t, resp, "Resp must be nil")
-		customEqual(t, err.Error(), "SearchServer fatal error")
+		if !errors.Is(err, ErrSearchServerFatal) {
+			t.Errorf("expected ErrSearchServerFatal, got %v", err)
+		}
 	})
 	t.Run("bad_request_unparsed_err", func(t *testing.T) {
 		req := NewSearchRequest(1, 1, 0, "BadRequest", "")
 		resp, err := sc.FindUsers(req)
 		respNil(t, resp, "Resp must be nil")
-		customEqual(t, err.Error(), "cant unpack error json: unexpected end of JSON input")
+		searchErr := asSearchError(t, err)
+		customEqual(t, searchErr.Kind, KindUnmarshal)
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Error("expected the underlying json.SyntaxError to be reachable via errors.As")
+		}
 	})
 	t.Run("bad_request_unparsed_err", func(t *testing.T) {
-		req := NewSearchRequest(1, 1, 0, "ErrorBadOrderField", "high")
+		req := NewSearchRequest(1, 1, 0, "ErrorBadOrderField", "Name")
 		resp, err := sc.FindUsers(req)
 		respNil(t, resp, "Resp must be nil")
-		customEqual(t, err.Error(), "OrderFeld high invalid")
+		if !errors.Is(err, ErrBadOrderField) {
+			t.Errorf("expected ErrBadOrderField, got %v", err)
+		}
+		searchErr := asSearchError(t, err)
+		customEqual(t, searchErr.Field, "Name")
 	})
 	t.Run("bad_request_unparsed_err", func(t *testing.T) {
 		req := NewSearchRequest(1, 1, 0, "Unknown", "")
 		resp, err := sc.FindUsers(req)
 		respNil(t, resp, "Resp must be nil")
-		customEqual(t, err.Error(), "unknown bad request error: Unknown")
+		if !errors.Is(err, ErrUnknownBadRequest) {
+			t.Errorf("expected ErrUnknownBadRequest, got %v", err)
+		}
 	})
 	t.Run("unparsed_results", func(t *testing.T) {
 		req := NewSearchRequest(1, 1, 0, "UnparsedResult", "")
 		resp, err := sc.FindUsers(req)
 		respNil(t, resp, "Resp must be nil")
-		customEqual(t, err.Error(), "cant unpack result json: unexpected end of JSON input")
+		searchErr := asSearchError(t, err)
+		customEqual(t, searchErr.Kind, KindUnmarshal)
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Error("expected the underlying json.SyntaxError to be reachable via errors.As")
+		}
 	})
 	t.Run("large_response", func(t *testing.T) {
 		req := NewSearchRequest(30, 0, 0, "LargeResponse", "")
@@ -149,27 +214,259 @@ func TestSearchClient_FindUsers(t *testing.T) {
 			t.Error("NextPage must be true")
 		}
 	})
-	t.Run("timeout", func(t *testing.T) {
+	t.Run("timeout_no_retry", func(t *testing.T) {
 		req := NewSearchRequest(30, 0, 0, "Timeout", "")
 		resp, err := sc.FindUsers(req)
 		respNil(t, resp, "Resp must be nil")
-		customEqual(t, err.Error(), "timeout for limit=26&offset=0&order_by=0&order_field=&query=Timeout")
+		searchErr := asSearchError(t, err)
+		customEqual(t, searchErr.Kind, KindTimeout)
+	})
+	t.Run("retry_recovers", func(t *testing.T) {
+		flaky, hits := newFlakyServer(2, http.StatusInternalServerError, OneUserSuccess)
+		defer flaky.Close()
+		rm := SearchClient{
+			AccessToken: SuccessAccessToken,
+			URL:         flaky.URL,
+			Options: ClientOptions{
+				MaxRetries:   2,
+				RetryBackoff: func(attempt int) time.Duration { return time.Millisecond },
+			},
+		}
+		req := NewSearchRequest(1, 0, 0, "Success", "")
+		resp, err := rm.FindUsers(req)
+		errNil(t, err, "Error must be nil")
+		if resp == nil || len(resp.Users) != 1 {
+			t.Error("expected one user once the server recovers")
+		}
+		if atomic.LoadInt32(hits) != 3 {
+			t.Errorf("expected 3 requests (2 failures + 1 success), got %d", atomic.LoadInt32(hits))
+		}
+	})
+	t.Run("retry_recovers_on_bad_gateway", func(t *testing.T) {
+		flaky, hits := newFlakyServer(2, http.StatusBadGateway, OneUserSuccess)
+		defer flaky.Close()
+		rm := SearchClient{
+			AccessToken: SuccessAccessToken,
+			URL:         flaky.URL,
+			Options: ClientOptions{
+				MaxRetries:   2,
+				RetryBackoff: func(attempt int) time.Duration { return time.Millisecond },
+			},
+		}
+		req := NewSearchRequest(1, 0, 0, "Success", "")
+		resp, err := rm.FindUsers(req)
+		errNil(t, err, "Error must be nil")
+		if resp == nil || len(resp.Users) != 1 {
+			t.Error("expected one user once the server recovers")
+		}
+		if atomic.LoadInt32(hits) != 3 {
+			t.Errorf("expected 3 requests (2 failures + 1 success), got %d", atomic.LoadInt32(hits))
+		}
+	})
+	t.Run("bad_gateway_classified_as_server_error", func(t *testing.T) {
+		flaky, _ := newFlakyServer(1000, http.StatusServiceUnavailable, OneUserSuccess)
+		defer flaky.Close()
+		rm := SearchClient{AccessToken: SuccessAccessToken, URL: flaky.URL}
+		req := NewSearchRequest(1, 0, 0, "Success", "")
+		_, err := rm.FindUsers(req)
+		se := asSearchError(t, err)
+		if se.Kind != KindServer {
+			t.Errorf("expected KindServer, got %v", se.Kind)
+		}
+		if se.Status != http.StatusServiceUnavailable {
+			t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, se.Status)
+		}
+	})
+	t.Run("breaker_trips", func(t *testing.T) {
+		flaky, hits := newFlakyServer(1000, http.StatusInternalServerError, OneUserSuccess)
+		defer flaky.Close()
+		rm := SearchClient{
+			AccessToken: SuccessAccessToken,
+			URL:         flaky.URL,
+			Options: ClientOptions{
+				RetryBackoff:     func(attempt int) time.Duration { return time.Millisecond },
+				BreakerThreshold: 3,
+				BreakerCooldown:  time.Minute,
+			},
+		}
+		req := NewSearchRequest(1, 0, 0, "Success", "")
+		for i := 0; i < 3; i++ {
+			_, err := rm.FindUsers(req)
+			if !errors.Is(err, ErrSearchServerFatal) {
+				t.Errorf("expected ErrSearchServerFatal, got %v", err)
+			}
+		}
+		_, err := rm.FindUsers(req)
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("expected ErrCircuitOpen once the breaker trips, got %v", err)
+		}
+		if atomic.LoadInt32(hits) != 3 {
+			t.Errorf("breaker should stop new requests reaching the server, got %d hits", atomic.LoadInt32(hits))
+		}
+	})
+	t.Run("context_cancelled_mid_request", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}()
+		req := NewSearchRequest(30, 0, 0, "Timeout", "")
+		resp, err := sc.FindUsersContext(ctx, req)
+		respNil(t, resp, "Resp must be nil")
+		if err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+	t.Run("context_deadline_exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req := NewSearchRequest(30, 0, 0, "Timeout", "")
+		resp, err := sc.FindUsersContext(ctx, req)
+		respNil(t, resp, "Resp must be nil")
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+	t.Run("cache_hit_skips_server", func(t *testing.T) {
+		counting, hits := newCountingServer(BabbleServer)
+		defer counting.Close()
+		rm := NewSearchClient(SuccessAccessToken, counting.URL)
+		rm.WithCache(time.Minute, 10)
+
+		req := NewSearchRequest(1, 0, 0, "Success", "")
+		_, err := rm.FindUsers(req)
+		errNil(t, err, "Error must be nil")
+		_, err = rm.FindUsers(req)
+		errNil(t, err, "Error must be nil")
+
+		if atomic.LoadInt32(hits) != 1 {
+			t.Errorf("expected 1 server hit, got %d", atomic.LoadInt32(hits))
+		}
+		stats := rm.CacheStats()
+		if stats.Hits != 1 || stats.Misses != 1 {
+			t.Errorf("expected 1 hit and 1 miss, got %+v", stats)
+		}
+	})
+	t.Run("cache_ttl_expiry_refetches", func(t *testing.T) {
+		counting, hits := newCountingServer(BabbleServer)
+		defer counting.Close()
+		rm := NewSearchClient(SuccessAccessToken, counting.URL)
+		rm.WithCache(10*time.Millisecond, 10)
+
+		req := NewSearchRequest(1, 0, 0, "Success", "")
+		_, err := rm.FindUsers(req)
+		errNil(t, err, "Error must be nil")
+		time.Sleep(20 * time.Millisecond)
+		_, err = rm.FindUsers(req)
+		errNil(t, err, "Error must be nil")
+
+		if atomic.LoadInt32(hits) != 2 {
+			t.Errorf("expected 2 server hits after TTL expiry, got %d", atomic.LoadInt32(hits))
+		}
+	})
+	t.Run("cache_does_not_store_errors", func(t *testing.T) {
+		counting, hits := newCountingServer(BabbleServer)
+		defer counting.Close()
+		rm := NewSearchClient(SuccessAccessToken, counting.URL)
+		rm.WithCache(time.Minute, 10)
+
+		req := NewSearchRequest(1, 0, 0, "Fatal", "")
+		_, err := rm.FindUsers(req)
+		if err == nil {
+			t.Error("expected an error from the Fatal case")
+		}
+		_, err = rm.FindUsers(req)
+		if err == nil {
+			t.Error("expected an error from the Fatal case")
+		}
+		if atomic.LoadInt32(hits) != 2 {
+			t.Errorf("expected errors to always re-hit the server, got %d hits", atomic.LoadInt32(hits))
+		}
 	})
 	t.Run("unknown_error", func(t *testing.T) {
 		rm := NewSearchClient(SuccessAccessToken, "")
 		req := NewSearchRequest(30, 0, 0, "UnknownError", "")
 		resp, err := rm.FindUsers(req)
 		respNil(t, resp, "Resp must be nil")
-		if !strings.Contains(err.Error(), `unknown error`) {
-			t.Error("Error must contains: unknown error")
-		}
+		searchErr := asSearchError(t, err)
+		customEqual(t, searchErr.Kind, KindTransport)
 	})
 	t.Run("unauthorized", func(t *testing.T) {
 		rm := NewSearchClient("", testServer.URL)
 		req := NewSearchRequest(1, 0, 0, "Unauthorized", "")
 		resp, err := rm.FindUsers(req)
 		respNil(t, resp, "Resp must be nil")
-		customEqual(t, err.Error(), `Bad AccessToken`)
+		if !errors.Is(err, ErrBadAccessToken) {
+			t.Errorf("expected ErrBadAccessToken, got %v", err)
+		}
+	})
+	t.Run("invalid_order_field", func(t *testing.T) {
+		req := NewSearchRequest(1, 0, 0, "Success", "Surname")
+		resp, err := sc.FindUsers(req)
+		respNil(t, resp, "Resp must be nil")
+		if !errors.Is(err, ErrBadOrderField) {
+			t.Errorf("expected ErrBadOrderField, got %v", err)
+		}
+		searchErr := asSearchError(t, err)
+		customEqual(t, searchErr.Field, "Surname")
+	})
+	t.Run("invalid_order_fields", func(t *testing.T) {
+		req := NewSearchRequest(1, 0, 0, "Success", "")
+		req.OrderFields = []OrderKey{{Field: "Id", By: OrderByAsc}, {Field: "Surname", By: OrderByDesc}}
+		resp, err := sc.FindUsers(req)
+		respNil(t, resp, "Resp must be nil")
+		if !errors.Is(err, ErrBadOrderField) {
+			t.Errorf("expected ErrBadOrderField, got %v", err)
+		}
+		searchErr := asSearchError(t, err)
+		customEqual(t, searchErr.Field, "Surname")
+	})
+	t.Run("mixed_offset_and_cursor_rejected", func(t *testing.T) {
+		req := NewSearchRequest(1, 1, 0, "Success", "")
+		req.Cursor = encodeCursor(1)
+		resp, err := sc.FindUsers(req)
+		respNil(t, resp, "Resp must be nil")
+		searchErr := asSearchError(t, err)
+		customEqual(t, searchErr.Kind, KindValidation)
+		customEqual(t, searchErr.Field, "Cursor")
+	})
+	t.Run("iterate_large_result_set", func(t *testing.T) {
+		req := NewSearchRequest(3, 0, 0, "LargeResponse", "")
+		it := sc.Iterate(req)
+		defer it.Close()
+
+		seen := []int{}
+		for {
+			u, err := it.Next(context.Background())
+			if err != nil {
+				break
+			}
+			seen = append(seen, u.Id)
+		}
+		errNil(t, it.Err(), "Err must be nil at clean EOF")
+		expected := make([]int, 30)
+		for i := range expected {
+			expected[i] = i
+		}
+		customEqual(t, seen, expected)
+	})
+	t.Run("cursor_round_trip", func(t *testing.T) {
+		req := NewSearchRequest(3, 0, 0, "CursorPaging", "")
+		seen := []int{}
+		for {
+			resp, err := sc.FindUsers(req)
+			errNil(t, err, "Error must be nil")
+			for _, u := range resp.Users {
+				seen = append(seen, u.Id)
+			}
+			if !resp.NextPage {
+				break
+			}
+			req = NewSearchRequest(3, 0, 0, "CursorPaging", "")
+			req.Cursor = resp.NextCursor
+		}
+		expected := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+		customEqual(t, seen, expected)
 	})
 }
 
@@ -189,4 +486,13 @@ func customEqual(t * testing.T, c, v interface {}) {
 	if !reflect.DeepEqual(c, v) {
 		t.Error("Not equal")
 	}
+}
+
+func asSearchError(t *testing.T, err error) *SearchError {
+	t.Helper()
+	var searchErr *SearchError
+	if !errors.As(err, &searchErr) {
+		t.Fatalf("expected a *SearchError, got %T: %v", err, err)
+	}
+	return searchErr
 }
\ No newline at end of file
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorKind classifies a SearchError so callers can branch on failure type
+// without parsing message text.
+type ErrorKind int
+
+const (
+	KindValidation ErrorKind = iota
+	KindAuth
+	KindBadOrderField
+	KindTimeout
+	KindServer
+	KindTransport
+	KindUnmarshal
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindValidation:
+		return "validation"
+	case KindAuth:
+		return "auth"
+	case KindBadOrderField:
+		return "bad_order_field"
+	case KindTimeout:
+		return "timeout"
+	case KindServer:
+		return "server"
+	case KindTransport:
+		return "transport"
+	case KindUnmarshal:
+		return "unmarshal"
+	default:
+		return "unknown"
+	}
+}
+
+// Sentinel errors so callers can use errors.Is instead of matching on
+// message text. They are usually reached through a *SearchError's Unwrap
+// chain rather than returned bare.
+var (
+	ErrBadAccessToken    = errors.New("Bad AccessToken")
+	ErrSearchServerFatal = errors.New("SearchServer fatal error")
+	ErrBadOrderField     = errors.New("bad order field")
+	ErrUnknownBadRequest = errors.New("unknown bad request error")
+	ErrCircuitOpen       = errors.New("search client circuit breaker open")
+)
+
+// SearchError is returned by every failure path of SearchClient. Kind lets
+// callers branch on the failure category, Status carries the HTTP status
+// code when one applies, Field names the offending request field when
+// applicable, and Err (reachable via errors.Unwrap) is the underlying cause
+// - a sentinel above, or a wrapped transport/decode error.
+type SearchError struct {
+	Kind   ErrorKind
+	Status int
+	Field  string
+	Err    error
+}
+
+func (e *SearchError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("search error: %s", e.Kind)
+}
+
+func (e *SearchError) Unwrap() error {
+	return e.Err
+}
+
+func validationError(field string, err error) *SearchError {
+	return &SearchError{Kind: KindValidation, Field: field, Err: err}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"io"
+)
+
+type pageResult struct {
+	users []User
+	err   error
+}
+
+// UserIterator pages through a SearchRequest's full result set without
+// holding every User in memory at once. It prefetches the next page while
+// the caller processes the current one.
+type UserIterator struct {
+	cancel context.CancelFunc
+	pages  chan pageResult
+
+	current []User
+	idx     int
+	err     error
+}
+
+// Iterate starts paging through req in the background and returns an
+// iterator over every matching User. Call Close when done to release the
+// background goroutine early.
+func (srv *SearchClient) Iterate(req SearchRequest) *UserIterator {
+	ctx, cancel := context.WithCancel(context.Background())
+	it := &UserIterator{
+		cancel: cancel,
+		pages:  make(chan pageResult),
+	}
+	go it.run(ctx, srv, req)
+	return it
+}
+
+func (it *UserIterator) run(ctx context.Context, srv *SearchClient, req SearchRequest) {
+	defer close(it.pages)
+	for {
+		resp, err := srv.FindUsersContext(ctx, req)
+		if err != nil {
+			select {
+			case it.pages <- pageResult{err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		select {
+		case it.pages <- pageResult{users: resp.Users}:
+		case <-ctx.Done():
+			return
+		}
+
+		if !resp.NextPage {
+			return
+		}
+		req.Offset = 0
+		req.Cursor = resp.NextCursor
+	}
+}
+
+// Next returns the next User, fetching the next page transparently. It
+// returns io.EOF once every page has been consumed; any other error aborts
+// iteration and is also reported by Err.
+func (it *UserIterator) Next(ctx context.Context) (User, error) {
+	for {
+		if it.err != nil {
+			return User{}, it.err
+		}
+		if it.idx < len(it.current) {
+			u := it.current[it.idx]
+			it.idx++
+			return u, nil
+		}
+
+		select {
+		case page, ok := <-it.pages:
+			if !ok {
+				it.err = io.EOF
+				return User{}, io.EOF
+			}
+			if page.err != nil {
+				it.err = page.err
+				return User{}, page.err
+			}
+			if len(page.users) == 0 {
+				it.err = io.EOF
+				return User{}, io.EOF
+			}
+			it.current = page.users
+			it.idx = 0
+		case <-ctx.Done():
+			return User{}, ctx.Err()
+		}
+	}
+}
+
+// Err returns the error that stopped iteration, or nil if iteration ran to
+// completion (or hasn't been exhausted yet).
+func (it *UserIterator) Err() error {
+	if it.err == io.EOF {
+		return nil
+	}
+	return it.err
+}
+
+// Close stops the background prefetch goroutine. It is safe to call after
+// iteration has already finished.
+func (it *UserIterator) Close() error {
+	it.cancel()
+	return nil
+}